@@ -0,0 +1,139 @@
+package tablecodec
+
+import (
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb-common/codec"
+	"github.com/pingcap/tidb-common/types"
+)
+
+// RecordKey returns the key of the record with handle h in table tableID,
+// equivalent to EncodeRecordKey(tableID, h, 0) but named for the common
+// whole-row case instead of the per-column one.
+func RecordKey(tableID, h int64) []byte {
+	return EncodeRecordKey(tableID, h, 0)
+}
+
+// SortAndDedupHandles sorts handles ascending and removes duplicates in
+// place, returning the deduplicated prefix of the slice. RangeSplitter and
+// BatchHandleKeys expect deduplicated input so a repeated handle doesn't
+// turn into a repeated key in their output.
+func SortAndDedupHandles(handles []int64) []int64 {
+	SortHandles(handles)
+	if len(handles) == 0 {
+		return handles
+	}
+	n := 1
+	for _, h := range handles[1:] {
+		if h != handles[n-1] {
+			handles[n] = h
+			n++
+		}
+	}
+	return handles[:n]
+}
+
+// BatchHandleKeys encodes the record key of every handle in a single
+// backing array, instead of one allocation per handle as repeated calls to
+// RecordKey would.
+func BatchHandleKeys(tableID int64, handles []int64) [][]byte {
+	prefix := TableRecordPrefix(tableID)
+	const maxHandleKeyLen = 9 // codec.EncodeInt output length
+	buf := make([]byte, 0, len(handles)*(len(prefix)+maxHandleKeyLen))
+	keys := make([][]byte, len(handles))
+	for i, h := range handles {
+		start := len(buf)
+		buf = append(buf, prefix...)
+		buf = codec.EncodeInt(buf, h)
+		keys[i] = buf[start:len(buf):len(buf)]
+	}
+	return keys
+}
+
+// IndexKeyRange builds the [start, end) key range covering the indexed
+// values between lower and upper (either may be nil for an open bound), for
+// a single index scan. lowerInclusive/upperInclusive control whether rows
+// with exactly the bound value are included; codec's memcomparable
+// encoding keeps NULL sorting before any other value, so a nil lower bound
+// with lowerInclusive true is the correct way to start a scan that should
+// also see NULLs.
+func IndexKeyRange(tableID, indexID int64, lower, upper []types.Datum, lowerInclusive, upperInclusive bool) (start, end []byte, err error) {
+	prefix := TableIndexPrefix(tableID, indexID)
+
+	start = append([]byte{}, prefix...)
+	if len(lower) > 0 {
+		start, err = codec.EncodeKey(start, lower...)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
+	if !lowerInclusive && len(lower) > 0 {
+		// Push past every key with this exact prefix of values: whatever
+		// follows the encoded values in a key (more values, the handle) starts
+		// with a codec flag byte below 0xff, so all such keys sort before it.
+		start = append(start, 0xff)
+	}
+
+	end = append([]byte{}, prefix...)
+	if len(upper) > 0 {
+		end, err = codec.EncodeKey(end, upper...)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
+	if upperInclusive || len(upper) == 0 {
+		// Either include rows equal to upper, or (upper == nil) there's no
+		// upper bound at all: either way end just past every key with this
+		// value prefix, covering the whole index when upper is nil.
+		end = append(end, 0xff)
+	}
+	return start, end, nil
+}
+
+// RangeSplitter turns a set of handles into balanced [start, end) cop-task
+// ranges sized to roughly targetSize handles each, so a coprocessor client
+// building a huge scan doesn't pay for one key allocation per handle.
+type RangeSplitter struct {
+	tableID    int64
+	targetSize int
+}
+
+// NewRangeSplitter builds a splitter for tableID that aims for targetSize
+// handles per range.
+func NewRangeSplitter(tableID int64, targetSize int) *RangeSplitter {
+	if targetSize <= 0 {
+		targetSize = 1
+	}
+	return &RangeSplitter{tableID: tableID, targetSize: targetSize}
+}
+
+// KeyRange is a half-open [Start, End) range of record keys.
+type KeyRange struct {
+	Start []byte
+	End   []byte
+}
+
+// Split sorts and dedups handles, then chunks them into ranges of up to
+// targetSize consecutive handles, each covering [RecordKey(first),
+// RecordKey(last)+0x00).
+func (s *RangeSplitter) Split(handles []int64) []KeyRange {
+	handles = SortAndDedupHandles(handles)
+	if len(handles) == 0 {
+		return nil
+	}
+	ranges := make([]KeyRange, 0, (len(handles)+s.targetSize-1)/s.targetSize)
+	for len(handles) > 0 {
+		n := s.targetSize
+		if n > len(handles) {
+			n = len(handles)
+		}
+		chunk := handles[:n]
+		handles = handles[n:]
+		end := RecordKey(s.tableID, chunk[len(chunk)-1])
+		end = append(end, 0x00)
+		ranges = append(ranges, KeyRange{
+			Start: RecordKey(s.tableID, chunk[0]),
+			End:   end,
+		})
+	}
+	return ranges
+}
@@ -0,0 +1,97 @@
+package tablecodec
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/pingcap/tidb-common/types"
+)
+
+func TestSortAndDedupHandles(t *testing.T) {
+	cases := []struct {
+		in   []int64
+		want []int64
+	}{
+		{in: nil, want: []int64{}},
+		{in: []int64{5}, want: []int64{5}},
+		{in: []int64{3, 1, 1, 2, 2, 2, 3}, want: []int64{1, 2, 3}},
+	}
+	for _, c := range cases {
+		got := SortAndDedupHandles(append([]int64{}, c.in...))
+		if len(got) == 0 && len(c.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("SortAndDedupHandles(%v) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestRangeSplitterUnevenChunks(t *testing.T) {
+	s := NewRangeSplitter(42, 3)
+	handles := []int64{7, 1, 2, 6, 5, 4, 3}
+
+	ranges := s.Split(handles)
+	if len(ranges) != 3 {
+		t.Fatalf("got %d ranges, want 3", len(ranges))
+	}
+
+	wantBounds := [][2]int64{{1, 3}, {4, 6}, {7, 7}}
+	for i, want := range wantBounds {
+		wantStart := RecordKey(42, want[0])
+		wantEnd := append(append([]byte{}, RecordKey(42, want[1])...), 0x00)
+		if !bytes.Equal(ranges[i].Start, wantStart) {
+			t.Errorf("range %d start = %x, want %x", i, ranges[i].Start, wantStart)
+		}
+		if !bytes.Equal(ranges[i].End, wantEnd) {
+			t.Errorf("range %d end = %x, want %x", i, ranges[i].End, wantEnd)
+		}
+	}
+}
+
+func TestIndexKeyRangeBounds(t *testing.T) {
+	vals := []types.Datum{types.NewDatum(int64(10))}
+	prefix := TableIndexPrefix(42, 5)
+
+	cases := []struct {
+		name                           string
+		lower, upper                   []types.Datum
+		lowerInclusive, upperInclusive bool
+		wantStartIsPrefix              bool
+		wantEndSuffix                  byte
+	}{
+		{name: "open both ends", lower: nil, upper: nil, lowerInclusive: true, upperInclusive: false, wantStartIsPrefix: true, wantEndSuffix: 0xff},
+		{name: "inclusive bounds", lower: vals, upper: vals, lowerInclusive: true, upperInclusive: true, wantStartIsPrefix: false, wantEndSuffix: 0xff},
+		{name: "exclusive lower", lower: vals, upper: nil, lowerInclusive: false, upperInclusive: false, wantStartIsPrefix: false, wantEndSuffix: 0xff},
+	}
+	for _, c := range cases {
+		start, end, err := IndexKeyRange(42, 5, c.lower, c.upper, c.lowerInclusive, c.upperInclusive)
+		if err != nil {
+			t.Fatalf("%s: IndexKeyRange: %v", c.name, err)
+		}
+		if c.wantStartIsPrefix && !bytes.Equal(start, prefix) {
+			t.Errorf("%s: start = %x, want bare prefix %x", c.name, start, prefix)
+		}
+		if len(end) == 0 || end[len(end)-1] != c.wantEndSuffix {
+			t.Errorf("%s: end = %x, want trailing %#x", c.name, end, c.wantEndSuffix)
+		}
+		if !bytes.HasPrefix(start, prefix) || !bytes.HasPrefix(end, prefix) {
+			t.Errorf("%s: start/end must share the index prefix %x, got %x / %x", c.name, prefix, start, end)
+		}
+	}
+
+	// An exclusive lower bound must sort strictly after the same bound with
+	// lowerInclusive true, since it pushes past every key at that prefix.
+	inclStart, _, err := IndexKeyRange(42, 5, vals, nil, true, false)
+	if err != nil {
+		t.Fatalf("IndexKeyRange inclusive: %v", err)
+	}
+	exclStart, _, err := IndexKeyRange(42, 5, vals, nil, false, false)
+	if err != nil {
+		t.Fatalf("IndexKeyRange exclusive: %v", err)
+	}
+	if bytes.Compare(exclStart, inclStart) <= 0 {
+		t.Errorf("exclusive lower start %x should sort after inclusive lower start %x", exclStart, inclStart)
+	}
+}
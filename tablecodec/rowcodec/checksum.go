@@ -0,0 +1,91 @@
+package rowcodec
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb-common/types"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+const (
+	// flagChecksum marks a row carrying a trailing CRC32C checksum, set by
+	// EncodeRowWithChecksum.
+	flagChecksum byte = 1 << 1
+	// flagChecksumExtra marks that a second checksum follows the first,
+	// used by column-type-change reorg to tell an already-rewritten row
+	// (second slot) from one still pending rewrite (first slot only).
+	flagChecksumExtra byte = 1 << 2
+)
+
+func hasChecksum(flags byte) bool { return flags&flagChecksum != 0 }
+func hasExtraChecksum(flags byte) bool {
+	return flags&flagChecksum != 0 && flags&flagChecksumExtra != 0
+}
+
+// ChecksumDisabled is returned by VerifyChecksum when raw carries no
+// checksum at all. Checking for it lets a caller on the common
+// checksum-off path bail out before parseRow and the CRC32C recompute run,
+// instead of paying for a verify that was never going to find anything.
+var ChecksumDisabled = errors.New("rowcodec: row carries no checksum")
+
+// EncodeRowWithChecksum encodes row/colIDs exactly as Encoder.Encode does,
+// then appends the given CRC32C checksums after the payload and sets the
+// has-checksum flag bit(s) so VerifyChecksum knows to look for them.
+//
+// A DDL column-type-change job passes one checksum while a row still
+// matches its original schema, and two once it has rewritten the row under
+// the new schema: the first slot keeps covering the original encoding, the
+// second covers the rewritten one. That lets a restarted reorg distinguish
+// "already rewritten" rows from "still pending" ones via VerifyChecksum
+// without needing to duplicate the schema-diffing logic itself.
+func EncodeRowWithChecksum(row []types.Datum, colIDs []int64, checksums ...uint32) ([]byte, error) {
+	if len(checksums) == 0 || len(checksums) > 2 {
+		return nil, errors.Errorf("rowcodec: EncodeRowWithChecksum takes 1 or 2 checksums, got %d", len(checksums))
+	}
+	var e Encoder
+	buf, err := e.Encode(colIDs, row)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	buf[1] |= flagChecksum
+	if len(checksums) == 2 {
+		buf[1] |= flagChecksumExtra
+	}
+	tmp := make([]byte, 4)
+	for _, c := range checksums {
+		binary.LittleEndian.PutUint32(tmp, c)
+		buf = append(buf, tmp...)
+	}
+	return buf, nil
+}
+
+// VerifyChecksum recomputes the CRC32C over raw's not-null column bodies
+// (concatenated in colID-ascending order; EncodeRowWithChecksum stores the
+// caller's checksums as given and hashes nothing itself) and compares it
+// against the first checksum embedded in raw.
+// extra is the second checksum slot if raw carries one, 0 otherwise; it is
+// returned rather than verified here because only the caller knows what the
+// new-schema checksum is expected to be.
+func VerifyChecksum(raw []byte) (matched bool, extra uint32, err error) {
+	if len(raw) < 2 || raw[0] != CodecVer {
+		return false, 0, errors.Trace(errInvalidCodecVer)
+	}
+	if !hasChecksum(raw[1]) {
+		return false, 0, ChecksumDisabled
+	}
+	r, err := parseRow(raw)
+	if err != nil {
+		return false, 0, errors.Trace(err)
+	}
+	tail := raw[len(raw)-4:]
+	if hasExtraChecksum(raw[1]) {
+		extra = binary.LittleEndian.Uint32(tail)
+		tail = raw[len(raw)-8 : len(raw)-4]
+	}
+	want := binary.LittleEndian.Uint32(tail)
+	got := crc32.Checksum(r.data, crc32cTable)
+	return got == want, extra, nil
+}
@@ -0,0 +1,183 @@
+package rowcodec
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb-common/tipb"
+	"github.com/pingcap/tidb-common/types"
+)
+
+// ColInfo carries the schema a decoder needs for one column: its ID (to
+// look it up in the encoded row) and its type (to dispatch decodeColumnValue).
+type ColInfo struct {
+	ID int64
+	Tp *tipb.ColumnInfo
+}
+
+// row is the parsed-but-not-materialized view of a row-format v2 value:
+// the colID/offset arrays, ready for lookups, plus a pointer at the start
+// of the data section.
+type row struct {
+	large      bool
+	numNotNull int
+	numNull    int
+	colIDs     []byte // numNotNull+numNull entries of colIDSize(large) bytes each
+	offsets    []byte // numNotNull entries of offsetSize(large) bytes each
+	data       []byte
+}
+
+func parseRow(value []byte) (row, error) {
+	var r row
+	if len(value) < 2 || value[0] != CodecVer {
+		return r, errors.Trace(errInvalidCodecVer)
+	}
+	flags := value[1]
+	r.large = isLargeRow(flags)
+	rest := value[2:]
+
+	numNotNull, n := binary.Uvarint(rest)
+	if n <= 0 {
+		return r, errors.Errorf("rowcodec: bad numNotNull varint")
+	}
+	rest = rest[n:]
+	numNull, n := binary.Uvarint(rest)
+	if n <= 0 {
+		return r, errors.Errorf("rowcodec: bad numNull varint")
+	}
+	rest = rest[n:]
+
+	r.numNotNull = int(numNotNull)
+	r.numNull = int(numNull)
+
+	idSize := colIDSize(r.large)
+	idsLen := (r.numNotNull + r.numNull) * idSize
+	if len(rest) < idsLen {
+		return r, errors.Errorf("rowcodec: truncated column-ID array")
+	}
+	r.colIDs, rest = rest[:idsLen], rest[idsLen:]
+
+	offSize := offsetSize(r.large)
+	offsetsLen := r.numNotNull * offSize
+	if len(rest) < offsetsLen {
+		return r, errors.Errorf("rowcodec: truncated offset array")
+	}
+	r.offsets, rest = rest[:offsetsLen], rest[offsetsLen:]
+
+	var payloadLen int
+	if r.numNotNull > 0 {
+		payloadLen = int(r.offsetAt(r.numNotNull - 1))
+	}
+	if len(rest) < payloadLen {
+		return r, errors.Errorf("rowcodec: truncated row payload")
+	}
+	r.data = rest[:payloadLen]
+	return r, nil
+}
+
+func (r row) colIDAt(i int) int64 {
+	idSize := colIDSize(r.large)
+	b := r.colIDs[i*idSize:]
+	if idSize == smallColIDSize {
+		return int64(b[0])
+	}
+	return int64(binary.LittleEndian.Uint32(b))
+}
+
+func (r row) offsetAt(i int) uint32 {
+	offSize := offsetSize(r.large)
+	b := r.offsets[i*offSize:]
+	if offSize == smallOffsetSize {
+		return uint32(binary.LittleEndian.Uint16(b))
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+// findColumn binary-searches the not-null colID prefix for id. found is
+// false both when id is absent entirely and when it is present only in the
+// trailing null-ID group - either way the caller should treat it as NULL.
+func (r row) findColumn(id int64) (body []byte, found bool) {
+	i := sort.Search(r.numNotNull, func(i int) bool { return r.colIDAt(i) >= id })
+	if i >= r.numNotNull || r.colIDAt(i) != id {
+		return nil, false
+	}
+	start := uint32(0)
+	if i > 0 {
+		start = r.offsetAt(i - 1)
+	}
+	return r.data[start:r.offsetAt(i)], true
+}
+
+// DatumMapDecoder decodes selected columns of a row-format v2 value into a
+// map keyed by column ID, for callers (e.g. the executor) that need
+// random-access reads of a handful of columns out of a wide row.
+type DatumMapDecoder struct {
+	cols []ColInfo
+}
+
+// NewDatumMapDecoder builds a decoder for the given columns.
+func NewDatumMapDecoder(cols []ColInfo) *DatumMapDecoder {
+	return &DatumMapDecoder{cols: cols}
+}
+
+// DecodeToDatumMap decodes value, filling in row for any requested column
+// found inside it and leaving columns that are genuinely absent or NULL out
+// of the map. row may be nil, in which case a fresh map is allocated.
+func (d *DatumMapDecoder) DecodeToDatumMap(value []byte, row map[int64]types.Datum) (map[int64]types.Datum, error) {
+	r, err := parseRow(value)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if row == nil {
+		row = make(map[int64]types.Datum, len(d.cols))
+	}
+	for _, col := range d.cols {
+		body, found := r.findColumn(col.ID)
+		if !found {
+			continue
+		}
+		datum, err := decodeColumnValue(body, col.Tp)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		row[col.ID] = datum
+	}
+	return row, nil
+}
+
+// ChunkDecoder decodes a row-format v2 value directly into a types.Chunk,
+// for the bulk table/index scan path where materializing a per-row map
+// would be wasted allocation.
+type ChunkDecoder struct {
+	cols []ColInfo
+}
+
+// NewChunkDecoder builds a decoder that appends the given columns, in
+// order, to a chunk.
+func NewChunkDecoder(cols []ColInfo) *ChunkDecoder {
+	return &ChunkDecoder{cols: cols}
+}
+
+// DecodeToChunk decodes value and appends one row to chk, in the same
+// column order the decoder was built with. A column absent from value (or
+// explicitly NULL) is appended as NULL.
+func (d *ChunkDecoder) DecodeToChunk(value []byte, chk *types.Chunk) error {
+	r, err := parseRow(value)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for i, col := range d.cols {
+		body, found := r.findColumn(col.ID)
+		if !found {
+			chk.AppendNull(i)
+			continue
+		}
+		datum, err := decodeColumnValue(body, col.Tp)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		chk.AppendDatum(i, &datum)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package rowcodec
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb-common/types"
+)
+
+// Encoder packs a row into the row-format v2 value described in the package
+// doc. Reuse one Encoder across rows in a batch insert/write path: its
+// scratch slices (order/notNullIDs/nullIDs/bodies/buf) are kept and
+// re-sliced to length 0 rather than reallocated on every call, so only a
+// row wider than any row seen so far grows them. The []byte Encode returns
+// aliases the Encoder's internal buffer, so copy it before calling Encode
+// again if you need to hold onto it.
+type Encoder struct {
+	order      []int
+	notNullIDs []int64
+	nullIDs    []int64
+	bodies     [][]byte
+	buf        []byte
+	large      bool
+}
+
+// Encode encodes colIDs/row into a row-format v2 value. colIDs and row must
+// have the same length; a column is considered NULL when its Datum's Kind
+// is types.KindNull.
+func (e *Encoder) Encode(colIDs []int64, row []types.Datum) ([]byte, error) {
+	if len(colIDs) != len(row) {
+		return nil, errors.Errorf("rowcodec: colIDs and row length mismatch, %d vs %d", len(colIDs), len(row))
+	}
+	if cap(e.order) < len(colIDs) {
+		e.order = make([]int, len(colIDs))
+	}
+	e.order = e.order[:len(colIDs)]
+	for i := range e.order {
+		e.order[i] = i
+	}
+	sort.Slice(e.order, func(i, j int) bool { return colIDs[e.order[i]] < colIDs[e.order[j]] })
+
+	e.notNullIDs = e.notNullIDs[:0]
+	e.nullIDs = e.nullIDs[:0]
+	e.bodies = e.bodies[:0]
+	e.large = false
+	dataLen := 0
+
+	for _, idx := range e.order {
+		colID := colIDs[idx]
+		if colID > 0xff {
+			e.large = true
+		}
+		if row[idx].Kind() == types.KindNull {
+			e.nullIDs = append(e.nullIDs, colID)
+			continue
+		}
+		body, err := encodeColumnValue(row[idx])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		dataLen += len(body)
+		if dataLen > 0xffff {
+			e.large = true
+		}
+		e.notNullIDs = append(e.notNullIDs, colID)
+		e.bodies = append(e.bodies, body)
+	}
+
+	buf := e.buf[:0]
+	buf = append(buf, CodecVer)
+	flags := byte(0)
+	if e.large {
+		flags |= flagLargeRow
+	}
+	buf = append(buf, flags)
+	buf = appendUvarint(buf, uint64(len(e.notNullIDs)))
+	buf = appendUvarint(buf, uint64(len(e.nullIDs)))
+
+	idSize := colIDSize(e.large)
+	for _, id := range e.notNullIDs {
+		buf = appendColID(buf, id, idSize)
+	}
+	for _, id := range e.nullIDs {
+		buf = appendColID(buf, id, idSize)
+	}
+
+	offSize := offsetSize(e.large)
+	var cum uint32
+	for _, body := range e.bodies {
+		cum += uint32(len(body))
+		buf = appendOffset(buf, cum, offSize)
+	}
+	for _, body := range e.bodies {
+		buf = append(buf, body...)
+	}
+	e.buf = buf
+	return buf, nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendColID(buf []byte, id int64, size int) []byte {
+	if size == smallColIDSize {
+		return append(buf, byte(id))
+	}
+	tmp := make([]byte, 4)
+	binary.LittleEndian.PutUint32(tmp, uint32(id))
+	return append(buf, tmp...)
+}
+
+func appendOffset(buf []byte, off uint32, size int) []byte {
+	if size == smallOffsetSize {
+		tmp := make([]byte, 2)
+		binary.LittleEndian.PutUint16(tmp, uint16(off))
+		return append(buf, tmp...)
+	}
+	tmp := make([]byte, 4)
+	binary.LittleEndian.PutUint32(tmp, off)
+	return append(buf, tmp...)
+}
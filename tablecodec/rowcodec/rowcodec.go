@@ -0,0 +1,66 @@
+// Package rowcodec implements TiDB's row-format v2: a whole row is packed
+// into a single value under key t{tid}_r{h}, instead of one KV per column.
+// This amortizes the per-column IO and key overhead of the original
+// encoding, at the cost of having to decode the row layout before any
+// column can be read.
+//
+// Layout of an encoded value:
+//
+//	version(1) flags(1) numNotNull(varint) numNull(varint)
+//	colIDs[numNotNull+numNull] offsets[numNotNull] data
+//	[checksum1(4)] [checksum2(4)]
+//
+// colIDs holds the not-null column IDs in ascending order followed by the
+// null column IDs in ascending order, so the decoder can binary-search the
+// not-null prefix for a wanted column and treat anything past it (or any ID
+// missing entirely) as NULL. offsets[i] is the cumulative end offset of
+// column i's body within data, so column i's bytes are data[offsets[i-1]:offsets[i]].
+// colIDs and offsets use 1-byte/2-byte entries unless flagLargeRow is set, in
+// which case they widen to 4 bytes to cover colID/length values that no
+// longer fit.
+package rowcodec
+
+import "github.com/juju/errors"
+
+// CodecVer is the first byte of every value encoded by this package. It is
+// chosen as 0x80 because every value produced by the legacy per-column
+// codec (tablecodec.DecodeValue) begins with a codec flag byte that is
+// always less than 0x80, so peeking at byte 0 is enough to tell the two
+// formats apart.
+const CodecVer byte = 0x80
+
+// Row flag bits, stored in the byte right after CodecVer. The checksum
+// bits (flagChecksum/flagChecksumExtra) live in checksum.go next to the
+// only code that sets or reads them.
+const (
+	// flagLargeRow marks a row where some column ID doesn't fit in one byte
+	// or the column bodies' total encoded length (the last cumulative
+	// offset) doesn't fit in 16 bits. Large rows
+	// use 4-byte column IDs and 4-byte offsets throughout.
+	flagLargeRow byte = 1 << 0
+)
+
+const (
+	smallColIDSize  = 1
+	largeColIDSize  = 4
+	smallOffsetSize = 2
+	largeOffsetSize = 4
+)
+
+var errInvalidCodecVer = errors.New("rowcodec: not a row-format v2 value")
+
+func isLargeRow(flags byte) bool { return flags&flagLargeRow != 0 }
+
+func colIDSize(large bool) int {
+	if large {
+		return largeColIDSize
+	}
+	return smallColIDSize
+}
+
+func offsetSize(large bool) int {
+	if large {
+		return largeOffsetSize
+	}
+	return smallOffsetSize
+}
@@ -0,0 +1,248 @@
+package rowcodec
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-common/mysql"
+	"github.com/pingcap/tidb-common/tipb"
+	"github.com/pingcap/tidb-common/types"
+)
+
+// encodeVector builds the raw dimension+floats wire bytes a
+// types.VectorFloat32 wraps, mirroring what types.ParseVectorFloat32 expects
+// to read back.
+func encodeVector(elems []float32) []byte {
+	buf := make([]byte, 4+4*len(elems))
+	binary.LittleEndian.PutUint32(buf, uint32(len(elems)))
+	for i, f := range elems {
+		binary.LittleEndian.PutUint32(buf[4+4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func colInfo(id int64, tp tipb.MysqlType) ColInfo {
+	return ColInfo{ID: id, Tp: &tipb.ColumnInfo{Tp: tp}}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	colIDs := []int64{1, 2, 3}
+	row := []types.Datum{
+		types.NewDatum(int64(42)),
+		types.Datum{}, // NULL
+		types.NewDatum("hello"),
+	}
+
+	var e Encoder
+	value, err := e.Encode(colIDs, row)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if value[0] != CodecVer {
+		t.Fatalf("value[0] = %#x, want CodecVer %#x", value[0], CodecVer)
+	}
+
+	cols := []ColInfo{
+		colInfo(1, tipb.MysqlType_TypeLonglong),
+		colInfo(2, tipb.MysqlType_TypeLonglong),
+		colInfo(3, tipb.MysqlType_TypeVarchar),
+	}
+	decoded, err := NewDatumMapDecoder(cols).DecodeToDatumMap(value, nil)
+	if err != nil {
+		t.Fatalf("DecodeToDatumMap: %v", err)
+	}
+
+	if got := decoded[1].GetInt64(); got != 42 {
+		t.Errorf("col 1 = %d, want 42", got)
+	}
+	if _, ok := decoded[2]; ok {
+		t.Errorf("col 2 should be absent (NULL), got %v", decoded[2])
+	}
+	if got := string(decoded[3].GetBytes()); got != "hello" {
+		t.Errorf("col 3 = %q, want %q", got, "hello")
+	}
+}
+
+func TestEncodeDecodeLargeRow(t *testing.T) {
+	// A column ID above 0xff forces the large-row (4-byte colID/offset) path.
+	colIDs := []int64{1, 300}
+	row := []types.Datum{types.NewDatum(int64(1)), types.NewDatum(int64(2))}
+
+	var e Encoder
+	value, err := e.Encode(colIDs, row)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if !isLargeRow(value[1]) {
+		t.Fatalf("flags = %#x, want flagLargeRow set", value[1])
+	}
+
+	cols := []ColInfo{colInfo(300, tipb.MysqlType_TypeLonglong)}
+	decoded, err := NewDatumMapDecoder(cols).DecodeToDatumMap(value, nil)
+	if err != nil {
+		t.Fatalf("DecodeToDatumMap: %v", err)
+	}
+	if got := decoded[300].GetInt64(); got != 2 {
+		t.Errorf("col 300 = %d, want 2", got)
+	}
+}
+
+func TestEncoderReuseAcrossRows(t *testing.T) {
+	var e Encoder
+	first, err := e.Encode([]int64{1}, []types.Datum{types.NewDatum(int64(1))})
+	if err != nil {
+		t.Fatalf("Encode first: %v", err)
+	}
+	firstCopy := append([]byte{}, first...)
+
+	second, err := e.Encode([]int64{1, 2}, []types.Datum{types.NewDatum(int64(1)), types.NewDatum(int64(2))})
+	if err != nil {
+		t.Fatalf("Encode second: %v", err)
+	}
+
+	cols := []ColInfo{colInfo(1, tipb.MysqlType_TypeLonglong), colInfo(2, tipb.MysqlType_TypeLonglong)}
+	decoded, err := NewDatumMapDecoder(cols).DecodeToDatumMap(second, nil)
+	if err != nil {
+		t.Fatalf("DecodeToDatumMap: %v", err)
+	}
+	if decoded[1].GetInt64() != 1 || decoded[2].GetInt64() != 2 {
+		t.Fatalf("second row decoded wrong: %+v", decoded)
+	}
+	if string(firstCopy) == string(second) {
+		t.Fatalf("second encode should differ from the first")
+	}
+}
+
+func TestEncodeRowWithChecksum(t *testing.T) {
+	colIDs := []int64{1}
+	row := []types.Datum{types.NewDatum(int64(7))}
+	body, err := encodeColumnValue(row[0])
+	if err != nil {
+		t.Fatalf("encodeColumnValue: %v", err)
+	}
+	sum := crc32.Checksum(body, crc32cTable)
+
+	value, err := EncodeRowWithChecksum(row, colIDs, sum)
+	if err != nil {
+		t.Fatalf("EncodeRowWithChecksum: %v", err)
+	}
+	matched, extra, err := VerifyChecksum(value)
+	if err != nil {
+		t.Fatalf("VerifyChecksum: %v", err)
+	}
+	if !matched {
+		t.Errorf("checksum should match")
+	}
+	if extra != 0 {
+		t.Errorf("extra = %#x, want 0 (single checksum)", extra)
+	}
+
+	value2, err := EncodeRowWithChecksum(row, colIDs, sum, 0xcafef00d)
+	if err != nil {
+		t.Fatalf("EncodeRowWithChecksum with two checksums: %v", err)
+	}
+	matched, extra, err = VerifyChecksum(value2)
+	if err != nil {
+		t.Fatalf("VerifyChecksum: %v", err)
+	}
+	if !matched {
+		t.Errorf("first checksum should match")
+	}
+	if extra != 0xcafef00d {
+		t.Errorf("extra = %#x, want 0xcafef00d", extra)
+	}
+}
+
+func TestVerifyChecksumDisabled(t *testing.T) {
+	var e Encoder
+	value, err := e.Encode([]int64{1}, []types.Datum{types.NewDatum(int64(1))})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if _, _, err := VerifyChecksum(value); err != ChecksumDisabled {
+		t.Fatalf("err = %v, want ChecksumDisabled", err)
+	}
+}
+
+func TestDecimalPrecisionRoundTrip(t *testing.T) {
+	var dec types.Datum
+	d, err := mysql.ParseDecimal("1.0000")
+	if err != nil {
+		t.Fatalf("parse decimal: %v", err)
+	}
+	dec.SetValue(d)
+	dec.SetLength(5)
+	dec.SetFrac(4)
+
+	var e Encoder
+	value, err := e.Encode([]int64{1}, []types.Datum{dec})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	cols := []ColInfo{colInfo(1, tipb.MysqlType_TypeNewDecimal)}
+	decoded, err := NewDatumMapDecoder(cols).DecodeToDatumMap(value, nil)
+	if err != nil {
+		t.Fatalf("DecodeToDatumMap: %v", err)
+	}
+	got := decoded[1]
+	if got.Length() != 5 || got.Frac() != 4 {
+		t.Errorf("precision/frac = %d/%d, want 5/4", got.Length(), got.Frac())
+	}
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	var dur types.Datum
+	dur.SetValue(mysql.Duration{Duration: 12*time.Hour + 34*time.Minute})
+
+	var e Encoder
+	value, err := e.Encode([]int64{1}, []types.Datum{dur})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	cols := []ColInfo{colInfo(1, tipb.MysqlType_TypeDuration)}
+	decoded, err := NewDatumMapDecoder(cols).DecodeToDatumMap(value, nil)
+	if err != nil {
+		t.Fatalf("DecodeToDatumMap: %v", err)
+	}
+	got := decoded[1].GetMysqlDuration().Duration
+	want := 12*time.Hour + 34*time.Minute
+	if got != want {
+		t.Errorf("duration = %v, want %v", got, want)
+	}
+}
+
+func TestJSONAndVectorRoundTrip(t *testing.T) {
+	wantJSON := []byte{0x01, 0x02, 0x03}
+	var jsonDatum types.Datum
+	jsonDatum.SetValue(types.BinaryJSON{Value: wantJSON})
+
+	wantVector := encodeVector([]float32{1.5, 2.5})
+	var vecDatum types.Datum
+	vecDatum.SetVectorFloat32(types.VectorFloat32{Value: wantVector})
+
+	colIDs := []int64{1, 2}
+	row := []types.Datum{jsonDatum, vecDatum}
+
+	var e Encoder
+	value, err := e.Encode(colIDs, row)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	cols := []ColInfo{
+		colInfo(1, tipb.MysqlType_TypeJSON),
+		colInfo(2, tipb.MysqlType_TypeTiDBVectorFloat32),
+	}
+	decoded, err := NewDatumMapDecoder(cols).DecodeToDatumMap(value, nil)
+	if err != nil {
+		t.Fatalf("DecodeToDatumMap: %v", err)
+	}
+	if got := decoded[1].GetMysqlJSON().Value; string(got) != string(wantJSON) {
+		t.Errorf("json column = %v, want %v", got, wantJSON)
+	}
+	if got := decoded[2].GetVectorFloat32().Value; string(got) != string(wantVector) {
+		t.Errorf("vector column = %v, want %v", got, wantVector)
+	}
+}
@@ -0,0 +1,146 @@
+package rowcodec
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb-common/codec"
+	"github.com/pingcap/tidb-common/mysql"
+	"github.com/pingcap/tidb-common/tipb"
+	"github.com/pingcap/tidb-common/types"
+)
+
+// encodeColumnValue encodes a single non-null column value into its raw
+// row-format v2 body. Unlike the legacy per-column codec, no type flag is
+// written: the body is dispatched on the Datum's own Kind, and the reader
+// recovers the type from schema instead (see decodeColumnValue), so nothing
+// is duplicated into the payload.
+func encodeColumnValue(d types.Datum) ([]byte, error) {
+	switch d.Kind() {
+	case types.KindInt64:
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, uint64(d.GetInt64()))
+		return buf, nil
+	case types.KindUint64:
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, d.GetUint64())
+		return buf, nil
+	case types.KindFloat32:
+		buf := make([]byte, 4)
+		binary.LittleEndian.PutUint32(buf, math.Float32bits(d.GetFloat32()))
+		return buf, nil
+	case types.KindFloat64:
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(d.GetFloat64()))
+		return buf, nil
+	case types.KindBytes, types.KindString:
+		return d.GetBytes(), nil
+	case types.KindMysqlTime:
+		return d.GetMysqlTime().Marshal()
+	case types.KindMysqlDuration:
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, uint64(d.GetMysqlDuration().Duration))
+		return buf, nil
+	case types.KindMysqlDecimal:
+		return codec.EncodeDecimal(nil, d.GetMysqlDecimal(), d.Length(), d.Frac())
+	case types.KindMysqlEnum:
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, d.GetMysqlEnum().Value)
+		return buf, nil
+	case types.KindMysqlSet:
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, d.GetMysqlSet().Value)
+		return buf, nil
+	case types.KindMysqlBit:
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, d.GetMysqlBit().Value)
+		return buf, nil
+	case types.KindMysqlJSON:
+		return d.GetMysqlJSON().Value, nil
+	case types.KindVectorFloat32:
+		// Like BinaryJSON above, VectorFloat32 already wraps the raw
+		// dimension+floats wire bytes, so there's nothing to marshal.
+		return d.GetVectorFloat32().Value, nil
+	}
+	return nil, errors.Errorf("rowcodec: unsupported datum kind %d", d.Kind())
+}
+
+// decodeColumnValue decodes a single column body produced by
+// encodeColumnValue back into a Datum. It mirrors tablecodec.unflatten's
+// type dispatch so the two codecs stay in sync.
+func decodeColumnValue(body []byte, tp *tipb.ColumnInfo) (types.Datum, error) {
+	var d types.Datum
+	switch tp.GetTp() {
+	case tipb.MysqlType_TypeTiny, tipb.MysqlType_TypeShort, tipb.MysqlType_TypeYear, tipb.MysqlType_TypeInt24,
+		tipb.MysqlType_TypeLong, tipb.MysqlType_TypeLonglong:
+		u := binary.LittleEndian.Uint64(body)
+		if mysql.HasUnsignedFlag(uint(tp.GetFlag())) {
+			d.SetUint64(u)
+		} else {
+			d.SetInt64(int64(u))
+		}
+		return d, nil
+	case tipb.MysqlType_TypeFloat:
+		d.SetFloat32(math.Float32frombits(binary.LittleEndian.Uint32(body)))
+		return d, nil
+	case tipb.MysqlType_TypeDouble:
+		d.SetFloat64(math.Float64frombits(binary.LittleEndian.Uint64(body)))
+		return d, nil
+	case tipb.MysqlType_TypeTinyBlob, tipb.MysqlType_TypeMediumBlob, tipb.MysqlType_TypeBlob,
+		tipb.MysqlType_TypeLongBlob, tipb.MysqlType_TypeVarchar, tipb.MysqlType_TypeString:
+		d.SetBytes(body)
+		return d, nil
+	case tipb.MysqlType_TypeDate, tipb.MysqlType_TypeDatetime, tipb.MysqlType_TypeTimestamp:
+		var t mysql.Time
+		t.Type = uint8(tp.GetTp())
+		t.Fsp = int(tp.GetDecimal())
+		if err := t.Unmarshal(body); err != nil {
+			return d, errors.Trace(err)
+		}
+		d.SetValue(t)
+		return d, nil
+	case tipb.MysqlType_TypeDuration:
+		dur := mysql.Duration{Duration: time.Duration(binary.LittleEndian.Uint64(body))}
+		d.SetValue(dur)
+		return d, nil
+	case tipb.MysqlType_TypeNewDecimal:
+		dec, precision, frac, err := codec.DecodeDecimal(body)
+		if err != nil {
+			return d, errors.Trace(err)
+		}
+		d.SetValue(dec)
+		d.SetLength(precision)
+		d.SetFrac(frac)
+		return d, nil
+	case tipb.MysqlType_TypeEnum:
+		enum, err := mysql.ParseEnumValue(tp.Elems, binary.LittleEndian.Uint64(body))
+		if err != nil {
+			return d, errors.Trace(err)
+		}
+		d.SetValue(enum)
+		return d, nil
+	case tipb.MysqlType_TypeSet:
+		set, err := mysql.ParseSetValue(tp.Elems, binary.LittleEndian.Uint64(body))
+		if err != nil {
+			return d, errors.Trace(err)
+		}
+		d.SetValue(set)
+		return d, nil
+	case tipb.MysqlType_TypeBit:
+		d.SetValue(mysql.Bit{Value: binary.LittleEndian.Uint64(body), Width: int(tp.GetColumnLen())})
+		return d, nil
+	case tipb.MysqlType_TypeJSON:
+		d.SetValue(types.BinaryJSON{Value: body})
+		return d, nil
+	case tipb.MysqlType_TypeTiDBVectorFloat32:
+		vec, err := types.ParseVectorFloat32(body)
+		if err != nil {
+			return d, errors.Trace(err)
+		}
+		d.SetVectorFloat32(vec)
+		return d, nil
+	}
+	return d, errors.Errorf("rowcodec: unsupported column type %v", tp.GetTp())
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/ngaut/log"
 	"github.com/pingcap/tidb-common/codec"
 	"github.com/pingcap/tidb-common/mysql"
+	"github.com/pingcap/tidb-common/tablecodec/rowcodec"
 	"github.com/pingcap/tidb-common/tipb"
 	"github.com/pingcap/tidb-common/types"
 )
@@ -19,7 +20,7 @@ var (
 )
 
 func EncodeRecordKey(tableId int64, h int64, columnID int64) []byte {
-	recordPrefix := genTableRecordPrefix(tableId)
+	recordPrefix := TableRecordPrefix(tableId)
 	buf := make([]byte, 0, len(recordPrefix)+16)
 	buf = append(buf, recordPrefix...)
 	buf = codec.EncodeInt(buf, h)
@@ -63,8 +64,31 @@ func DecodeRecordKey(key []byte) (tableID int64, handle int64, columnID int64, e
 	return
 }
 
-// DecodeValue implements table.Table DecodeValue interface.
+// DecodeValue implements table.Table DecodeValue interface. It only
+// understands the legacy one-KV-per-column encoding; a value written under
+// the compact rowcodec.CodecVer row format must be decoded a row at a time
+// with rowcodec.DatumMapDecoder or rowcodec.ChunkDecoder instead.
 func DecodeValue(data []byte, tp *tipb.ColumnInfo) (types.Datum, error) {
+	if len(data) > 0 && data[0] == rowcodec.CodecVer {
+		return types.Datum{}, errors.Errorf("tablecodec: value is row-format v2, decode it with rowcodec instead of DecodeValue")
+	}
+	// Decimals are decoded directly with codec.DecodeDecimal rather than
+	// through the generic codec.Decode + unflatten path below, because
+	// DecodeDecimal hands back the precision/frac the value was encoded
+	// with. Going through the generic string-typed decode and reparsing
+	// with mysql.ParseDecimal loses that: "0.1" and "1.0000" parse to the
+	// same value but must keep their own display width.
+	if tp.GetTp() == tipb.MysqlType_TypeNewDecimal {
+		dec, precision, frac, err := codec.DecodeDecimal(data)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		var datum types.Datum
+		datum.SetValue(dec)
+		datum.SetLength(precision)
+		datum.SetFrac(frac)
+		return datum, nil
+	}
 	values, err := codec.Decode(data)
 	if err != nil {
 		return types.Datum{}, errors.Trace(err)
@@ -100,11 +124,19 @@ func unflatten(datum types.Datum, tp *tipb.ColumnInfo) (types.Datum, error) {
 		datum.SetValue(dur)
 		return datum, nil
 	case tipb.MysqlType_TypeNewDecimal:
+		// DecodeValue bypasses this case for the common path, decoding
+		// TypeNewDecimal values with codec.DecodeDecimal directly so it gets
+		// precision/frac straight from the encoding. Callers that reach
+		// unflatten with an already-flattened Datum instead - DecodeIndexKey
+		// chief among them - don't have the raw bytes to do that, so fall
+		// back to the column schema's own Flen/Decimal for precision/frac.
 		dec, err := mysql.ParseDecimal(datum.GetString())
 		if err != nil {
 			return datum, errors.Trace(err)
 		}
 		datum.SetValue(dec)
+		datum.SetLength(int(tp.GetColumnLen()))
+		datum.SetFrac(int(tp.GetDecimal()))
 		return datum, nil
 	case tipb.MysqlType_TypeEnum:
 		enum, err := mysql.ParseEnumValue(tp.Elems, datum.GetUint64())
@@ -124,12 +156,26 @@ func unflatten(datum types.Datum, tp *tipb.ColumnInfo) (types.Datum, error) {
 		bit := mysql.Bit{Value: datum.GetUint64(), Width: int(tp.GetColumnLen())}
 		datum.SetValue(bit)
 		return datum, nil
+	case tipb.MysqlType_TypeJSON:
+		// The datum already holds the raw TiDB JSON binary blob (type
+		// code, size, element/offset/key tables and values); wrap it
+		// rather than eagerly materializing a tree so callers that only
+		// want one key or element don't pay to decode the whole document.
+		datum.SetValue(types.BinaryJSON{Value: datum.GetBytes()})
+		return datum, nil
+	case tipb.MysqlType_TypeTiDBVectorFloat32:
+		vec, err := types.ParseVectorFloat32(datum.GetBytes())
+		if err != nil {
+			return datum, errors.Trace(err)
+		}
+		datum.SetVectorFloat32(vec)
+		return datum, nil
 	}
 	log.Error(tp.GetTp(), datum)
 	return datum, nil
 }
 
-func EncodeIndexKey(tableId int64, indexedValues []types.Datum, handle int64, unique bool) (key []byte, distinct bool, err error) {
+func EncodeIndexKey(tableId int64, indexID int64, indexedValues []types.Datum, handle int64, unique bool) (key []byte, distinct bool, err error) {
 	if unique {
 		// See: https://dev.mysql.com/doc/refman/5.7/en/create-index.html
 		// A UNIQUE index creates a constraint such that all values in the index must be distinct.
@@ -143,7 +189,7 @@ func EncodeIndexKey(tableId int64, indexedValues []types.Datum, handle int64, un
 			}
 		}
 	}
-	prefix := genTableIndexPrefix(tableId)
+	prefix := TableIndexPrefix(tableId, indexID)
 	key = append(key, prefix...)
 	if distinct {
 		key, err = codec.EncodeKey(key, indexedValues...)
@@ -156,8 +202,65 @@ func EncodeIndexKey(tableId int64, indexedValues []types.Datum, handle int64, un
 	return key, distinct, nil
 }
 
-// record prefix is "t[tableID]_r"
-func genTableRecordPrefix(tableID int64) []byte {
+// DecodeIndexKey strips the "t{tableID}_i{indexID}" prefix off key and
+// decodes the indexed column values, dispatching each through unflatten per
+// colTypes[i] the same way DecodeValue does. The trailing handle that
+// EncodeIndexKey appends for every non-unique index, and for a unique index
+// whenever one of the indexed values is NULL, is popped and returned too;
+// for a unique index with no NULL the key carries no handle at all (see
+// EncodeIndexKey's distinct case), so handle comes back 0 and callers must
+// get it from the index value instead.
+//
+// This lets tools like CDC, backup and reparo reconstruct a row straight
+// from an index-only scan instead of keeping their own copy of this
+// package's key layout.
+func DecodeIndexKey(key []byte, colTypes []*tipb.ColumnInfo, unique bool) (tableID, indexID int64, values []types.Datum, handle int64, err error) {
+	k := key
+	if !key.HasPrefix(TablePrefix) {
+		return 0, 0, nil, 0, errors.Errorf("invalid index key - %q", k)
+	}
+	key = key[len(TablePrefix):]
+	key, tableID, err = codec.DecodeInt(key)
+	if err != nil {
+		return 0, 0, nil, 0, errors.Trace(err)
+	}
+	if !key.HasPrefix(indexPrefixSep) {
+		return 0, 0, nil, 0, errors.Errorf("invalid index key - %q", k)
+	}
+	key = key[len(indexPrefixSep):]
+	key, indexID, err = codec.DecodeInt(key)
+	if err != nil {
+		return 0, 0, nil, 0, errors.Trace(err)
+	}
+
+	decoded, err := codec.Decode(key)
+	if err != nil {
+		return 0, 0, nil, 0, errors.Trace(err)
+	}
+
+	if len(decoded) < len(colTypes) {
+		return 0, 0, nil, 0, errors.Errorf("invalid index key - %q: expected at least %d indexed values, decoded %d", k, len(colTypes), len(decoded))
+	}
+	hasHandle := len(decoded) > len(colTypes)
+	if !unique && !hasHandle {
+		return 0, 0, nil, 0, errors.Errorf("invalid index key - %q: non-unique index key carries no handle", k)
+	}
+	values = make([]types.Datum, len(colTypes))
+	for i, tp := range colTypes {
+		values[i], err = unflatten(decoded[i], tp)
+		if err != nil {
+			return 0, 0, nil, 0, errors.Trace(err)
+		}
+	}
+	if hasHandle {
+		handle = decoded[len(colTypes)].GetInt64()
+	}
+	return tableID, indexID, values, handle, nil
+}
+
+// TableRecordPrefix returns the key prefix "t{tableID}_r" shared by every
+// record key of the table, i.e. the start of the table's record range.
+func TableRecordPrefix(tableID int64) []byte {
 	buf := make([]byte, 0, len(TablePrefix)+8+len(recordPrefixSep))
 	buf = append(buf, TablePrefix...)
 	buf = codec.EncodeInt(buf, tableID)
@@ -165,12 +268,15 @@ func genTableRecordPrefix(tableID int64) []byte {
 	return buf
 }
 
-// index prefix is "t[tableID]_i"
-func genTableIndexPrefix(tableID int64) []byte {
-	buf := make([]byte, 0, len(TablePrefix)+8+len(indexPrefixSep))
+// TableIndexPrefix returns the key prefix "t{tableID}_i{indexID}" shared by
+// every key of that one index, i.e. the start of the index's own range
+// within the table.
+func TableIndexPrefix(tableID, indexID int64) []byte {
+	buf := make([]byte, 0, len(TablePrefix)+16+len(indexPrefixSep))
 	buf = append(buf, TablePrefix...)
 	buf = codec.EncodeInt(buf, tableID)
 	buf = append(buf, indexPrefixSep...)
+	buf = codec.EncodeInt(buf, indexID)
 	return buf
 }
 
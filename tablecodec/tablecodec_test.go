@@ -0,0 +1,79 @@
+package tablecodec
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb-common/mysql"
+	"github.com/pingcap/tidb-common/tipb"
+	"github.com/pingcap/tidb-common/types"
+)
+
+func TestEncodeDecodeIndexKeyRoundTrip(t *testing.T) {
+	values := []types.Datum{types.NewDatum(int64(1)), types.NewDatum("abc")}
+	key, distinct, err := EncodeIndexKey(42, 5, values, 100, false)
+	if err != nil {
+		t.Fatalf("EncodeIndexKey: %v", err)
+	}
+	if distinct {
+		t.Fatalf("non-unique index should never be distinct")
+	}
+
+	colTypes := []*tipb.ColumnInfo{
+		{Tp: tipb.MysqlType_TypeLonglong},
+		{Tp: tipb.MysqlType_TypeVarchar},
+	}
+	tableID, indexID, decoded, handle, err := DecodeIndexKey(key, colTypes, false)
+	if err != nil {
+		t.Fatalf("DecodeIndexKey: %v", err)
+	}
+	if tableID != 42 || indexID != 5 {
+		t.Errorf("tableID/indexID = %d/%d, want 42/5", tableID, indexID)
+	}
+	if handle != 100 {
+		t.Errorf("handle = %d, want 100", handle)
+	}
+	if decoded[0].GetInt64() != 1 || string(decoded[1].GetBytes()) != "abc" {
+		t.Errorf("decoded values wrong: %+v", decoded)
+	}
+}
+
+func TestDecodeIndexKeyRejectsSchemaMismatch(t *testing.T) {
+	// A unique index with no NULL indexed values carries no trailing handle
+	// (see EncodeIndexKey's distinct case), so decoded has exactly as many
+	// entries as values - here, 1. Asking DecodeIndexKey for 2 colTypes
+	// against that key must return an error, not panic indexing decoded[1].
+	values := []types.Datum{types.NewDatum(int64(1))}
+	key, distinct, err := EncodeIndexKey(42, 5, values, 100, true)
+	if err != nil {
+		t.Fatalf("EncodeIndexKey: %v", err)
+	}
+	if !distinct {
+		t.Fatalf("unique index with no NULL values should be distinct")
+	}
+
+	colTypes := []*tipb.ColumnInfo{
+		{Tp: tipb.MysqlType_TypeLonglong},
+		{Tp: tipb.MysqlType_TypeLonglong},
+	}
+	if _, _, _, _, err := DecodeIndexKey(key, colTypes, true); err == nil {
+		t.Fatalf("expected an error decoding against a mismatched schema, got nil")
+	}
+}
+
+func TestUnflattenDecimalFallsBackToSchemaPrecision(t *testing.T) {
+	dec, err := mysql.ParseDecimal("1.0000")
+	if err != nil {
+		t.Fatalf("ParseDecimal: %v", err)
+	}
+	var datum types.Datum
+	datum.SetString(dec.String())
+
+	tp := &tipb.ColumnInfo{Tp: tipb.MysqlType_TypeNewDecimal, ColumnLen: 5, Decimal: 4}
+	got, err := unflatten(datum, tp)
+	if err != nil {
+		t.Fatalf("unflatten: %v", err)
+	}
+	if got.Length() != 5 || got.Frac() != 4 {
+		t.Errorf("precision/frac = %d/%d, want 5/4", got.Length(), got.Frac())
+	}
+}